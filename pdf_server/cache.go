@@ -0,0 +1,28 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+const cacheDir = "uploads/cache"
+
+// cachePath returns the on-disk location for a rendered page:
+// uploads/cache/<sha256(filename)>/<dpi>/<page><ext>, where ext matches
+// whatever format the configured renderer backend actually produces.
+func cachePath(filename string, page, dpi int, ext string) string {
+	hash := sha256Hex(filename)
+	return filepath.Join(cacheDir, hash, strconv.Itoa(dpi), fmt.Sprintf("%d%s", page, ext))
+}
+
+func sha256Hex(s string) string {
+	return sha256HexBytes([]byte(s))
+}
+
+func sha256HexBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
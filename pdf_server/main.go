@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -14,6 +15,8 @@ import (
 	"strings"
 )
 
+var renderer = newRenderer()
+
 func main() {
 	currentDir, err := os.Getwd()
 	if err != nil {
@@ -24,6 +27,10 @@ func main() {
 	// 修改路径为 '/api/go/upload' 和 '/api/go/show'
 	http.HandleFunc("/upload", uploadHandler)
 	http.HandleFunc("/show", showHandler)
+	http.HandleFunc("/showRange", showRangeHandler)
+	http.HandleFunc("/prefetch", prefetchHandler)
+	http.HandleFunc("/merge", mergeHandler)
+	http.HandleFunc("/ocr", ocrHandler)
 
 	// 在所有网络接口上监听 3001 端口
 	log.Println("Starting server on 0.0.0.0:3001")
@@ -48,6 +55,15 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Println("Handling POST request")
+
+	// A Content-Range header marks one chunk of a resumable upload; the
+	// server assembles chunks on disk and finalizes once the last one lands.
+	if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+		handleChunkedUpload(w, r, contentRange)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes())
 	file, header, err := r.FormFile("pdf")
 	if err != nil {
 		log.Println("Error reading PDF file:", err)
@@ -57,36 +73,109 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	log.Println("Received file:", header.Filename)
-	pdfPath := filepath.Join("uploads", header.Filename)
-	out, err := os.Create(pdfPath)
+
+	baseName, err := sanitizeUploadFilename(header.Filename)
 	if err != nil {
-		log.Println("Error creating PDF file:", err)
-		http.Error(w, "Error creating PDF file", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, file)
+	data, err := io.ReadAll(file)
 	if err != nil {
-		log.Println("Error saving PDF file:", err)
-		http.Error(w, "Error saving PDF file", http.StatusInternalServerError)
+		log.Println("Error reading PDF file:", err)
+		http.Error(w, "Error reading PDF file (too large?)", http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	log.Println("File saved:", pdfPath)
+	finishUpload(w, data, baseName)
+}
 
-	// Get the PDF page count
-	totalPages, err := getPDFPageCount(header.Filename)
-	if err != nil {
-		log.Printf("getPDFPageCount error: %v", err)
-		http.Error(w, "Error getting PDF page count", http.StatusInternalServerError)
+// mergeHandler accepts a multipart POST with multiple "pdf" file parts and a
+// parallel "range" part per file (e.g. "1-3,5,7-"; empty/absent means "all
+// pages"), merges the selected pages into a single PDF, and streams it back.
+func mergeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Return the filename and total page count
-	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Write([]byte(fmt.Sprintf(`{"filename": "%s", "totalPages": %d}`, header.Filename, totalPages)))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes())
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		log.Println("Error parsing multipart form:", err)
+		http.Error(w, "Error parsing multipart form", http.StatusBadRequest)
+		return
+	}
+
+	files := r.MultipartForm.File["pdf"]
+	if len(files) < 2 {
+		http.Error(w, "At least two pdf parts are required to merge", http.StatusBadRequest)
+		return
+	}
+	ranges := r.MultipartForm.Value["range"]
+
+	tmpDir, err := os.MkdirTemp("uploads", "merge-upload-")
+	if err != nil {
+		log.Println("Error creating merge upload dir:", err)
+		http.Error(w, "Error creating merge upload dir", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputs := make([]MergeInput, 0, len(files))
+	for i, fh := range files {
+		src, err := fh.Open()
+		if err != nil {
+			log.Println("Error opening uploaded pdf part:", err)
+			http.Error(w, "Error reading uploaded pdf", http.StatusBadRequest)
+			return
+		}
+
+		dstPath := filepath.Join(tmpDir, fmt.Sprintf("input-%d.pdf", i))
+		dst, err := os.Create(dstPath)
+		if err != nil {
+			src.Close()
+			log.Println("Error creating temp merge file:", err)
+			http.Error(w, "Error saving uploaded pdf", http.StatusInternalServerError)
+			return
+		}
+		_, err = io.Copy(dst, src)
+		src.Close()
+		dst.Close()
+		if err != nil {
+			log.Println("Error saving uploaded pdf part:", err)
+			http.Error(w, "Error saving uploaded pdf", http.StatusInternalServerError)
+			return
+		}
+
+		pageRanges := ""
+		if i < len(ranges) {
+			pageRanges = ranges[i]
+		}
+		inputs = append(inputs, MergeInput{Path: dstPath, PageRanges: pageRanges})
+	}
+
+	merged, err := mergePDFs(inputs)
+	if err != nil {
+		log.Printf("mergePDFs error: %v", err)
+		http.Error(w, "Error merging PDFs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="merged.pdf"`)
+	if _, err := io.Copy(w, merged); err != nil {
+		log.Printf("error streaming merged PDF: %v", err)
+	}
 }
 
 func showHandler(w http.ResponseWriter, r *http.Request) {
@@ -121,14 +210,15 @@ func showHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert the specified page to an image
-	imgPath, err := convertPDFPageToImage(filename, page)
+	dpi := clampDPI(parseDPI(r))
+
+	// Render (or serve from cache) the specified page
+	imgPath, err := pipeline.renderPage(filename, page, dpi)
 	if err != nil {
-		log.Printf("convertPDFPageToImage error: %v", err)
+		log.Printf("renderPage error: %v", err)
 		http.Error(w, "Error converting PDF to image", http.StatusInternalServerError)
 		return
 	}
-	defer os.Remove(imgPath)
 
 	// Convert the image to Base64
 	base64Img, err := imageToBase64(imgPath)
@@ -138,11 +228,209 @@ func showHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// OCR is opt-in via ?ocr=1 so plain image requests aren't slowed down.
+	if r.URL.Query().Get("ocr") == "1" {
+		regions, err := ocrForPage(filename, page, dpi, imgPath)
+		if err != nil {
+			log.Printf("ocrForPage error: %v", err)
+			http.Error(w, "Error running OCR", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"image":   base64Img,
+			"regions": regions,
+		})
+		return
+	}
+
 	response := fmt.Sprintf(`{"image": "%s"}`, base64Img)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(response))
 }
 
+// ocrHandler is the companion to /show for callers that only want the OCR
+// text layer for a page (e.g. to snap selection boxes to word boundaries).
+func ocrHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	filename := r.URL.Query().Get("filename")
+	pageStr := r.URL.Query().Get("page")
+	if filename == "" || pageStr == "" {
+		http.Error(w, "Missing filename or page parameter", http.StatusBadRequest)
+		return
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		http.Error(w, "Invalid page number", http.StatusBadRequest)
+		return
+	}
+
+	dpi := clampDPI(parseDPI(r))
+
+	imgPath, err := pipeline.renderPage(filename, page, dpi)
+	if err != nil {
+		log.Printf("renderPage error: %v", err)
+		http.Error(w, "Error converting PDF to image", http.StatusInternalServerError)
+		return
+	}
+
+	regions, err := ocrForPage(filename, page, dpi, imgPath)
+	if err != nil {
+		log.Printf("ocrForPage error: %v", err)
+		http.Error(w, "Error running OCR", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"regions": regions})
+}
+
+// showRangeHandler renders a contiguous page range in a single request so the
+// frontend can prefetch several pages (e.g. current page first, then its
+// neighbors) without N separate /show round-trips.
+func showRangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		http.Error(w, "Missing filename parameter", http.StatusBadRequest)
+		return
+	}
+
+	firstPage, err := strconv.Atoi(r.URL.Query().Get("firstPage"))
+	if err != nil || firstPage < 1 {
+		http.Error(w, "Invalid firstPage parameter", http.StatusBadRequest)
+		return
+	}
+
+	lastPage, err := strconv.Atoi(r.URL.Query().Get("lastPage"))
+	if err != nil || lastPage < firstPage {
+		http.Error(w, "Invalid lastPage parameter", http.StatusBadRequest)
+		return
+	}
+
+	dpi := clampDPI(parseDPI(r))
+
+	filename, lastPage, err = validatePageRange(filename, firstPage, lastPage)
+	if err != nil {
+		log.Printf("validatePageRange error: %v", err)
+		http.Error(w, "Invalid page range", http.StatusBadRequest)
+		return
+	}
+
+	images := make(map[string]string, lastPage-firstPage+1)
+	for page := firstPage; page <= lastPage; page++ {
+		imgPath, err := pipeline.renderPage(filename, page, dpi)
+		if err != nil {
+			log.Printf("renderPage error for page %d: %v", page, err)
+			http.Error(w, "Error converting PDF range to images", http.StatusInternalServerError)
+			return
+		}
+		base64Img, err := imageToBase64(imgPath)
+		if err != nil {
+			log.Printf("imageToBase64 error: %v", err)
+			http.Error(w, "Error converting image to Base64", http.StatusInternalServerError)
+			return
+		}
+		images[strconv.Itoa(page)] = base64Img
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"images": images}); err != nil {
+		log.Printf("error encoding showRange response: %v", err)
+	}
+}
+
+// prefetchHandler enqueues a page range for background rendering so the cache
+// is warm by the time the UI actually asks for those pages. It returns
+// immediately; callers poll /show or /showRange to pick up the results.
+func prefetchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		http.Error(w, "Missing filename parameter", http.StatusBadRequest)
+		return
+	}
+
+	firstPage, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || firstPage < 1 {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+
+	lastPage, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || lastPage < firstPage {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	dpi := clampDPI(parseDPI(r))
+
+	filename, lastPage, err = validatePageRange(filename, firstPage, lastPage)
+	if err != nil {
+		log.Printf("validatePageRange error: %v", err)
+		http.Error(w, "Invalid page range", http.StatusBadRequest)
+		return
+	}
+
+	pipeline.prefetchRange(filename, firstPage, lastPage, dpi)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status": "queued"}`))
+}
+
+// parseDPI reads the optional "dpi" query parameter, returning 0 when absent
+// or invalid so callers fall back to clampDPI's default.
+func parseDPI(r *http.Request) int {
+	dpi, err := strconv.Atoi(r.URL.Query().Get("dpi"))
+	if err != nil {
+		return 0
+	}
+	return dpi
+}
+
 func getPDFPageCount(pdfFilename string) (int, error) {
 	pdfPath := filepath.Join("uploads", pdfFilename)
 
@@ -178,35 +466,6 @@ func getPDFPageCount(pdfFilename string) (int, error) {
 	return 0, fmt.Errorf("page count not found in pdfinfo output")
 }
 
-func convertPDFPageToImage(pdfFilename string, page int) (string, error) {
-	pdfPath := filepath.Join("uploads", pdfFilename)
-	outputDir := "uploads" // 保存生成的图像的目录
-	os.MkdirAll(outputDir, os.ModePerm)
-
-	// 构建输出文件前缀路径
-	outputBaseName := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
-	outputPath := filepath.Join(outputDir, fmt.Sprintf("%s-page-%d", outputBaseName, page))
-
-	// 使用 -singlefile 确保生成的文件名唯一
-	cmd := exec.Command("pdftoppm", "-png", "-f", strconv.Itoa(page), "-l", strconv.Itoa(page), "-singlefile", pdfPath, outputPath)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	log.Printf("Running command: %s", cmd.String())
-	err := cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("pdftoppm error: %v, stderr: %s", err, stderr.String())
-	}
-
-	// 生成的文件名为 outputPath.png
-	imageFile := outputPath + ".png"
-	if _, err := os.Stat(imageFile); os.IsNotExist(err) {
-		return "", fmt.Errorf("image file not found: %s", imageFile)
-	}
-
-	log.Printf("Generated image file: %s", imageFile)
-	return imageFile, nil
-}
-
 func imageToBase64(imgPath string) (string, error) {
 	imgFile, err := os.Open(imgPath)
 	if err != nil {
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// MergeInput is one file participating in a /merge request: the PDF to pull
+// pages from, plus an optional page-range selector such as "1-3,5,7-". An
+// empty PageRanges means "all pages".
+type MergeInput struct {
+	Path       string
+	PageRanges string
+}
+
+// parsePageRanges expands a selector like "1-3,5,7-" against totalPages into
+// the pdfcpu page-selection syntax (one entry per comma-separated term),
+// validating every bound along the way.
+func parsePageRanges(spec string, totalPages int) ([]string, error) {
+	if spec == "" {
+		return []string{fmt.Sprintf("1-%d", totalPages)}, nil
+	}
+
+	var selection []string
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		if !strings.Contains(term, "-") {
+			page, err := strconv.Atoi(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid page %q", term)
+			}
+			if page < 1 || page > totalPages {
+				return nil, fmt.Errorf("page %d out of range (document has %d pages)", page, totalPages)
+			}
+			selection = append(selection, term)
+			continue
+		}
+
+		bounds := strings.SplitN(term, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("invalid range start in %q", term)
+		}
+
+		to := totalPages
+		if bounds[1] != "" {
+			to, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid range end in %q", term)
+			}
+		}
+		if to > totalPages {
+			return nil, fmt.Errorf("range %q exceeds document page count (%d)", term, totalPages)
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid range %q", term)
+		}
+		selection = append(selection, fmt.Sprintf("%d-%d", from, to))
+	}
+
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("empty page range %q", spec)
+	}
+	return selection, nil
+}
+
+// mergePDFs trims each input to its selected pages and merges the results
+// into a single PDF, returned as an io.Reader over a temp file.
+func mergePDFs(inputs []MergeInput) (io.Reader, error) {
+	tmpDir, err := os.MkdirTemp("uploads", "merge-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating merge scratch dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	trimmedFiles := make([]string, 0, len(inputs))
+	for i, input := range inputs {
+		totalPages, err := api.PageCountFile(input.Path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading page count for %s: %v", input.Path, err)
+		}
+
+		selection, err := parsePageRanges(input.PageRanges, totalPages)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(input.Path), err)
+		}
+
+		trimmedPath := filepath.Join(tmpDir, fmt.Sprintf("trimmed-%d.pdf", i))
+		if err := api.TrimFile(input.Path, trimmedPath, selection, nil); err != nil {
+			return nil, fmt.Errorf("error trimming %s: %v", input.Path, err)
+		}
+		trimmedFiles = append(trimmedFiles, trimmedPath)
+	}
+
+	outPath := filepath.Join(tmpDir, "merged.pdf")
+	if err := api.MergeCreateFile(trimmedFiles, outPath, false, nil); err != nil {
+		return nil, fmt.Errorf("error merging PDFs: %v", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading merged PDF: %v", err)
+	}
+
+	return bytes.NewReader(data), nil
+}
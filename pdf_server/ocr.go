@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// OCRRegion is one recognized word/line, in image pixel coordinates at the
+// DPI the page was rendered at.
+type OCRRegion struct {
+	BBox [4]int  `json:"bbox"`
+	Text string  `json:"text"`
+	Conf float64 `json:"conf"`
+}
+
+var (
+	hocrWordRe = regexp.MustCompile(`(?s)<span class='ocrx_word'[^>]*title="bbox (\d+) (\d+) (\d+) (\d+); x_wconf (\d+)"[^>]*>(.*?)</span>`)
+	tagStripRe = regexp.MustCompile(`<[^>]+>`)
+)
+
+// ocrForPage returns the OCR regions for a rendered page, reading them from
+// the on-disk cache when present and running tesseract otherwise.
+func ocrForPage(filename string, page, dpi int, imgPath string) ([]OCRRegion, error) {
+	cachePath := ocrCachePath(filename, page, dpi)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var regions []OCRRegion
+		if err := json.Unmarshal(data, &regions); err == nil {
+			return regions, nil
+		}
+	}
+
+	regions, err := runTesseract(imgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(regions); err == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+			log.Printf("error creating OCR cache dir for %s: %v", cachePath, err)
+		} else if err := os.WriteFile(cachePath, data, 0644); err != nil {
+			log.Printf("error caching OCR result for %s: %v", cachePath, err)
+		}
+	}
+
+	return regions, nil
+}
+
+// ocrCachePath mirrors cachePath so the OCR JSON for a page lives next to
+// its rendered PNG: uploads/cache/<sha256(filename)>/<dpi>/<page>.ocr.json
+func ocrCachePath(filename string, page, dpi int) string {
+	hash := sha256Hex(filename)
+	return filepath.Join(cacheDir, hash, strconv.Itoa(dpi), fmt.Sprintf("%d.ocr.json", page))
+}
+
+// runTesseract invokes tesseract with hOCR output on the rendered image and
+// parses the result into OCRRegions.
+func runTesseract(imgPath string) ([]OCRRegion, error) {
+	cmd := exec.Command("tesseract", imgPath, "stdout", "hocr")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tesseract error: %v, stderr: %s", err, stderr.String())
+	}
+	return parseHOCR(stdout.String())
+}
+
+// parseHOCR pulls ocrx_word spans out of hOCR markup into OCRRegions.
+func parseHOCR(hocr string) ([]OCRRegion, error) {
+	matches := hocrWordRe.FindAllStringSubmatch(hocr, -1)
+	regions := make([]OCRRegion, 0, len(matches))
+	for _, m := range matches {
+		x0, _ := strconv.Atoi(m[1])
+		y0, _ := strconv.Atoi(m[2])
+		x1, _ := strconv.Atoi(m[3])
+		y1, _ := strconv.Atoi(m[4])
+		conf, _ := strconv.Atoi(m[5])
+
+		text := html.UnescapeString(tagStripRe.ReplaceAllString(m[6], ""))
+		if text == "" {
+			continue
+		}
+
+		regions = append(regions, OCRRegion{
+			BBox: [4]int{x0, y0, x1 - x0, y1 - y0},
+			Text: text,
+			Conf: float64(conf) / 100,
+		})
+	}
+	return regions, nil
+}
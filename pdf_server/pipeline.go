@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const defaultMaxRenders = 10
+
+// renderCall tracks an in-flight render so concurrent requests for the same
+// (filename, page, dpi) share a single pdftoppm/pdfium invocation instead of
+// spawning duplicate processes.
+type renderCall struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+// renderPipeline is a bounded-worker rendering pipeline backed by the on-disk
+// page cache. At most maxRenders pages are rendered concurrently, and
+// concurrent requests for the same page are collapsed into one render.
+type renderPipeline struct {
+	mu    sync.Mutex
+	calls map[string]*renderCall
+	sem   chan struct{}
+}
+
+var pipeline = newRenderPipeline()
+
+func newRenderPipeline() *renderPipeline {
+	max := defaultMaxRenders
+	if v := os.Getenv("MAX_RENDERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			max = n
+		}
+	}
+	return &renderPipeline{
+		calls: make(map[string]*renderCall),
+		sem:   make(chan struct{}, max),
+	}
+}
+
+func renderJobKey(filename string, page, dpi int) string {
+	return fmt.Sprintf("%s|%d|%d", filename, page, dpi)
+}
+
+// renderPage returns the cached PNG path for (filename, page, dpi), rendering
+// it first if necessary. Concurrent callers for the same page block on the
+// same in-flight render rather than each spawning their own pdftoppm process.
+func (p *renderPipeline) renderPage(filename string, page, dpi int) (string, error) {
+	filename, err := sanitizeUploadFilename(filename)
+	if err != nil {
+		return "", err
+	}
+
+	dest := cachePath(filename, page, dpi, renderer.Ext())
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	key := renderJobKey(filename, page, dpi)
+
+	p.mu.Lock()
+	if call, ok := p.calls[key]; ok {
+		p.mu.Unlock()
+		<-call.done
+		return call.path, call.err
+	}
+	call := &renderCall{done: make(chan struct{})}
+	p.calls[key] = call
+	p.mu.Unlock()
+
+	p.sem <- struct{}{}
+	call.path, call.err = renderToCache(filename, page, dpi, dest)
+	<-p.sem
+
+	p.mu.Lock()
+	delete(p.calls, key)
+	p.mu.Unlock()
+	close(call.done)
+
+	return call.path, call.err
+}
+
+// validatePageRange sanitizes filename and clamps [firstPage, lastPage] to
+// the document's real page count and to maxRangeSpan, so /showRange and
+// /prefetch can't be used to fan out an unbounded amount of work.
+func validatePageRange(filename string, firstPage, lastPage int) (safeName string, clampedLastPage int, err error) {
+	safeName, err = sanitizeUploadFilename(filename)
+	if err != nil {
+		return "", 0, err
+	}
+
+	totalPages, err := getPDFPageCount(safeName)
+	if err != nil {
+		return "", 0, err
+	}
+	if firstPage > totalPages {
+		return "", 0, fmt.Errorf("firstPage %d exceeds document page count (%d)", firstPage, totalPages)
+	}
+
+	clampedLastPage = lastPage
+	if clampedLastPage > totalPages {
+		clampedLastPage = totalPages
+	}
+	if clampedLastPage-firstPage+1 > maxRangeSpan {
+		clampedLastPage = firstPage + maxRangeSpan - 1
+	}
+
+	return safeName, clampedLastPage, nil
+}
+
+// prefetchRange enqueues firstPage..lastPage for background rendering so the
+// cache is warm by the time the UI asks for them; errors are logged rather
+// than surfaced since there's no caller waiting on the result.
+func (p *renderPipeline) prefetchRange(filename string, firstPage, lastPage, dpi int) {
+	for page := firstPage; page <= lastPage; page++ {
+		page := page
+		go func() {
+			if _, err := p.renderPage(filename, page, dpi); err != nil {
+				log.Printf("prefetch render error for %s page %d: %v", filename, page, err)
+			}
+		}()
+	}
+}
+
+// renderToCache renders a single page into a scratch directory and moves the
+// result into place at dest, so partial writes never show up as cache hits.
+func renderToCache(filename string, page, dpi int, dest string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating cache dir for %s: %v", dest, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("uploads", "render-tmp-")
+	if err != nil {
+		return "", fmt.Errorf("error creating scratch render dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pdfPath := filepath.Join("uploads", filename)
+	images, err := renderer.RenderRange(pdfPath, page, page, dpi, tmpDir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(images[page], dest); err != nil {
+		return "", fmt.Errorf("error moving rendered page into cache: %v", err)
+	}
+
+	return dest, nil
+}
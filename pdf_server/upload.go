@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxUploadBytes = 50 << 20 // 50MB
+
+var (
+	allowedUploadExts = map[string]bool{".pdf": true}
+	pdfMagic          = []byte("%PDF-")
+	contentRangeRe    = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+	unsafeIDCharsRe   = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+func maxUploadBytes() int64 {
+	if v := os.Getenv("UPLOAD_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxUploadBytes
+}
+
+// sanitizeUploadFilename strips any directory component from a client-
+// supplied filename and enforces the upload extension allowlist, so
+// filepath.Join("uploads", name) can never escape the uploads directory.
+func sanitizeUploadFilename(name string) (string, error) {
+	base := filepath.Base(filepath.Clean(name))
+	if base == "." || base == ".." || base == string(filepath.Separator) || strings.Contains(base, "..") {
+		return "", fmt.Errorf("invalid filename %q", name)
+	}
+
+	ext := strings.ToLower(filepath.Ext(base))
+	if !allowedUploadExts[ext] {
+		return "", fmt.Errorf("unsupported file extension %q", ext)
+	}
+
+	return base, nil
+}
+
+// looksLikePDF sniffs the standard %PDF- header rather than trusting the
+// client-declared content type.
+func looksLikePDF(data []byte) bool {
+	return len(data) >= len(pdfMagic) && string(data[:len(pdfMagic)]) == string(pdfMagic)
+}
+
+// hashedUploadName renames an upload to <content-hash>-<original-basename>
+// so two uploads with the same name never collide on disk.
+func hashedUploadName(data []byte, baseName string) string {
+	return fmt.Sprintf("%s-%s", sha256HexBytes(data)[:16], baseName)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error encoding json response: %v", err)
+	}
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header,
+// as sent by a chunked/resumable upload client.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	m := contentRangeRe.FindStringSubmatch(header)
+	if m == nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range header %q", header)
+	}
+	start, _ = strconv.ParseInt(m[1], 10, 64)
+	end, _ = strconv.ParseInt(m[2], 10, 64)
+	total, _ = strconv.ParseInt(m[3], 10, 64)
+	if start > end || end >= total {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range bounds %q", header)
+	}
+	return start, end, total, nil
+}
+
+// chunkUploadDir returns the scratch directory chunks for a given upload are
+// assembled in, under uploads/.chunks/<safe id>.
+func chunkUploadDir(uploadID string) string {
+	safeID := unsafeIDCharsRe.ReplaceAllString(uploadID, "_")
+	if safeID == "" {
+		safeID = "upload"
+	}
+	return filepath.Join("uploads", ".chunks", safeID)
+}
+
+// assembleChunks concatenates the *.part files in dir in chunk order. Parts
+// are named by their zero-padded start offset so a lexical sort is also a
+// numeric sort.
+func assembleChunks(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading chunk dir %s: %v", dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var out []byte
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("error reading chunk %s: %v", name, err)
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// handleChunkedUpload assembles one chunk of a resumable upload (driven by
+// the Content-Range header) and, once the last chunk arrives, finalizes the
+// file atomically: the assembled PDF is written to a temp path and renamed
+// into place only after it passes the PDF sniff check.
+func handleChunkedUpload(w http.ResponseWriter, r *http.Request, contentRange string) {
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if total > maxUploadBytes() {
+		http.Error(w, "Upload exceeds maximum allowed size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes())
+	file, header, err := r.FormFile("pdf")
+	if err != nil {
+		log.Println("Error reading PDF chunk:", err)
+		http.Error(w, "Error reading PDF chunk", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	baseName, err := sanitizeUploadFilename(header.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uploadID := r.FormValue("uploadId")
+	if uploadID == "" {
+		uploadID = baseName
+	}
+	chunkDir := chunkUploadDir(uploadID)
+	if err := os.MkdirAll(chunkDir, os.ModePerm); err != nil {
+		log.Println("Error creating chunk dir:", err)
+		http.Error(w, "Error creating chunk dir", http.StatusInternalServerError)
+		return
+	}
+
+	chunkPath := filepath.Join(chunkDir, fmt.Sprintf("%020d.part", start))
+	out, err := os.Create(chunkPath)
+	if err != nil {
+		log.Println("Error creating chunk file:", err)
+		http.Error(w, "Error saving chunk", http.StatusInternalServerError)
+		return
+	}
+	_, err = io.CopyN(out, file, end-start+1)
+	out.Close()
+	if err != nil {
+		log.Println("Error saving chunk:", err)
+		http.Error(w, "Error saving chunk", http.StatusInternalServerError)
+		return
+	}
+
+	// Bound disk usage as chunks accumulate, independent of what the final
+	// chunk's Content-Range claims the total to be.
+	if received, err := dirSize(chunkDir); err != nil {
+		log.Println("Error statting chunk dir:", err)
+		http.Error(w, "Error saving chunk", http.StatusInternalServerError)
+		return
+	} else if received > maxUploadBytes() {
+		os.RemoveAll(chunkDir)
+		http.Error(w, "Upload exceeds maximum allowed size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if end+1 < total {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "chunk received", "received": end + 1, "total": total})
+		return
+	}
+
+	data, err := assembleChunks(chunkDir)
+	if err != nil {
+		log.Println("Error assembling chunks:", err)
+		http.Error(w, "Error assembling chunks", http.StatusInternalServerError)
+		return
+	}
+	if int64(len(data)) != total {
+		// A chunk is still missing (e.g. reordered on a flaky connection) —
+		// keep what's been received so far instead of forcing a restart.
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "chunk received", "received": int64(len(data)), "total": total})
+		return
+	}
+	os.RemoveAll(chunkDir)
+
+	finishUpload(w, data, baseName)
+}
+
+// dirSize sums the size of regular files directly inside dir, used to cap
+// on-disk chunk accumulation during a resumable upload.
+func dirSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// finishUpload validates, hash-renames, and atomically stores a fully
+// assembled upload, then responds with its filename and page count.
+func finishUpload(w http.ResponseWriter, data []byte, baseName string) {
+	if !looksLikePDF(data) {
+		http.Error(w, "File does not look like a PDF", http.StatusBadRequest)
+		return
+	}
+
+	finalName := hashedUploadName(data, baseName)
+	finalPath := filepath.Join("uploads", finalName)
+	tmpPath := finalPath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Println("Error writing PDF file:", err)
+		http.Error(w, "Error saving PDF file", http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		log.Println("Error finalizing PDF file:", err)
+		http.Error(w, "Error saving PDF file", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("File saved:", finalPath)
+
+	totalPages, err := getPDFPageCount(finalName)
+	if err != nil {
+		log.Printf("getPDFPageCount error: %v", err)
+		http.Error(w, "Error getting PDF page count", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"filename": finalName, "totalPages": totalPages})
+}